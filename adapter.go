@@ -0,0 +1,308 @@
+package etcdstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// KVAdapter abstracts the TTL-capable key/value backend that EtcdStore
+// persists session data to. Implementing this interface against a store
+// other than etcd (Redis, TiKV, an in-memory map, GAE datastore, ...) lets
+// that backend reuse EtcdStore's session-serialization and cookie-encoding
+// logic unchanged.
+type KVAdapter interface {
+	// Get fetches the value stored at key. ok is false if key does not
+	// exist (including if it has expired); err is only non-nil for
+	// backend failures.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Put stores value at key, expiring it after ttl. A ttl <= 0 means
+	// the value never expires.
+	Put(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key. It is not an error if key does not exist.
+	Delete(ctx context.Context, key string) error
+}
+
+// cachedLease is a lease previously granted for a given key, kept around
+// so it can be refreshed instead of re-granted. revision is the etcd mod
+// revision of the most recent successful Put for the key, independent of
+// whether lease reuse is enabled - PutRevision uses it to let callers
+// (CascadeStore's watch) recognize their own writes.
+type cachedLease struct {
+	id       clientv3.LeaseID
+	ttl      time.Duration
+	revision int64
+}
+
+// pendingPut is a write queued by the write-behind batcher.
+type pendingPut struct {
+	key   string
+	value []byte
+	ttl   time.Duration
+	done  chan error
+}
+
+// etcdAdapter is the default KVAdapter, backed by a real etcd cluster.
+//
+// By default it grants a brand new lease on every Put, same as the
+// original implementation. SetLeaseReuse and SetWriteBatch (reached via
+// EtcdStore.SetLeaseReuse / EtcdStore.SetWriteBatch) opt into reusing
+// leases across writes to the same key and coalescing concurrent writes
+// into a single Txn, both of which cut down on etcd RPCs under load.
+type etcdAdapter struct {
+	client *clientv3.Client
+	kv     clientv3.KV
+	lease  clientv3.Lease
+
+	leaseMu    sync.Mutex
+	leaseReuse bool
+	leases     *leaseCache
+
+	batchMu       sync.Mutex
+	batchMaxOps   int
+	batchMaxDelay time.Duration
+	pending       []pendingPut
+	flushTimer    *time.Timer
+}
+
+// NewEtcdAdapter wraps an existing *clientv3.Client as a KVAdapter.
+func NewEtcdAdapter(client *clientv3.Client) KVAdapter {
+	return &etcdAdapter{
+		client: client,
+		kv:     client.KV,
+		lease:  client.Lease,
+		leases: newLeaseCache(defaultLeaseCacheCapacity),
+	}
+}
+
+// SetLeaseReuse enables or disables lease reuse. See EtcdStore.SetLeaseReuse.
+func (a *etcdAdapter) SetLeaseReuse(enable bool) {
+	a.leaseMu.Lock()
+	defer a.leaseMu.Unlock()
+	a.leaseReuse = enable
+}
+
+// SetWriteBatch configures the write-behind batcher. See
+// EtcdStore.SetWriteBatch.
+func (a *etcdAdapter) SetWriteBatch(maxOps int, maxDelay time.Duration) {
+	a.batchMu.Lock()
+	defer a.batchMu.Unlock()
+	a.batchMaxOps = maxOps
+	a.batchMaxDelay = maxDelay
+}
+
+func (a *etcdAdapter) batchEnabled() bool {
+	a.batchMu.Lock()
+	defer a.batchMu.Unlock()
+	return a.batchMaxOps > 0
+}
+
+func (a *etcdAdapter) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	resp, err := a.kv.Get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp == nil || len(resp.Kvs) != 1 {
+		return nil, false, nil
+	}
+	return resp.Kvs[0].Value, true, nil
+}
+
+func (a *etcdAdapter) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if a.batchEnabled() {
+		return a.enqueue(ctx, key, value, ttl)
+	}
+	return a.putDirect(ctx, key, value, ttl)
+}
+
+func (a *etcdAdapter) putDirect(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		resp, err := a.kv.Put(ctx, key, string(value))
+		if err != nil {
+			return err
+		}
+		a.recordRevisionFromHeader(key, resp)
+		return nil
+	}
+
+	leaseID, err := a.leaseFor(ctx, key, ttl)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.kv.Put(ctx, key, string(value), clientv3.WithLease(leaseID))
+	if err != nil {
+		return err
+	}
+	a.recordRevisionFromHeader(key, resp)
+	return nil
+}
+
+// recordRevisionFromHeader records resp's revision for key, if resp carries
+// one. A nil Header (e.g. from a test fake) is treated as unknown, not an
+// error.
+func (a *etcdAdapter) recordRevisionFromHeader(key string, resp *clientv3.PutResponse) {
+	if resp == nil || resp.Header == nil {
+		return
+	}
+	a.recordRevision(key, resp.Header.Revision)
+}
+
+// leaseFor returns a lease good for ttl for key, reusing and refreshing a
+// previously granted lease when lease reuse is enabled and ttl hasn't
+// changed since it was granted. Falls back to granting a new lease if
+// reuse is disabled, the ttl changed, or the cached lease no longer exists
+// (e.g. it expired).
+func (a *etcdAdapter) leaseFor(ctx context.Context, key string, ttl time.Duration) (clientv3.LeaseID, error) {
+	a.leaseMu.Lock()
+	reuse := a.leaseReuse
+	a.leaseMu.Unlock()
+	cached, ok := a.leases.Get(key)
+
+	if reuse && ok && cached.ttl == ttl {
+		if _, err := a.lease.KeepAliveOnce(ctx, cached.id); err == nil {
+			return cached.id, nil
+		}
+		// The cached lease is gone (expired/revoked) - grant a new one below.
+	}
+
+	granted, err := a.lease.Grant(ctx, int64(ttl/time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	if reuse {
+		a.leases.Set(key, cachedLease{id: granted.ID, ttl: ttl})
+	}
+	return granted.ID, nil
+}
+
+// recordRevision records the etcd mod revision of the most recent
+// successful Put for key, preserving any lease already cached for it. It
+// uses the same bounded leases cache rather than a separate unbounded map,
+// so tracking revisions doesn't reintroduce the growth problem that cache
+// was added to fix.
+func (a *etcdAdapter) recordRevision(key string, revision int64) {
+	cached, _ := a.leases.Get(key)
+	cached.revision = revision
+	a.leases.Set(key, cached)
+}
+
+// PutRevision returns the etcd mod revision of the most recent successful
+// Put for key known to this adapter, if any. CascadeStore uses this to
+// recognize its own writes in watchInvalidations and avoid evicting the
+// local-tier entry it just populated.
+func (a *etcdAdapter) PutRevision(key string) (int64, bool) {
+	cached, ok := a.leases.Get(key)
+	if !ok || cached.revision == 0 {
+		return 0, false
+	}
+	return cached.revision, true
+}
+
+// enqueue adds a write to the pending batch, flushing immediately once
+// batchMaxOps writes are queued or batchMaxDelay has elapsed since the
+// first of them, then blocks for that flush's result.
+func (a *etcdAdapter) enqueue(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	put := pendingPut{key: key, value: value, ttl: ttl, done: make(chan error, 1)}
+
+	a.batchMu.Lock()
+	a.pending = append(a.pending, put)
+	var toFlush []pendingPut
+	if len(a.pending) >= a.batchMaxOps {
+		toFlush, a.pending = a.pending, nil
+		if a.flushTimer != nil {
+			a.flushTimer.Stop()
+			a.flushTimer = nil
+		}
+	} else if a.flushTimer == nil {
+		a.flushTimer = time.AfterFunc(a.batchMaxDelay, a.flushPending)
+	}
+	a.batchMu.Unlock()
+
+	if toFlush != nil {
+		a.flush(context.Background(), toFlush)
+	}
+
+	select {
+	case err := <-put.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *etcdAdapter) flushPending() {
+	a.batchMu.Lock()
+	toFlush := a.pending
+	a.pending = nil
+	a.flushTimer = nil
+	a.batchMu.Unlock()
+
+	a.flush(context.Background(), toFlush)
+}
+
+// flush commits every queued write as a single Txn.
+func (a *etcdAdapter) flush(ctx context.Context, pending []pendingPut) {
+	if len(pending) == 0 {
+		return
+	}
+
+	ops := make([]clientv3.Op, 0, len(pending))
+	keys := make([]string, 0, len(pending))
+	dones := make([]chan error, 0, len(pending))
+	for _, p := range pending {
+		if p.ttl <= 0 {
+			ops = append(ops, clientv3.OpPut(p.key, string(p.value)))
+			keys = append(keys, p.key)
+			dones = append(dones, p.done)
+			continue
+		}
+		leaseID, err := a.leaseFor(ctx, p.key, p.ttl)
+		if err != nil {
+			p.done <- err
+			continue
+		}
+		ops = append(ops, clientv3.OpPut(p.key, string(p.value), clientv3.WithLease(leaseID)))
+		keys = append(keys, p.key)
+		dones = append(dones, p.done)
+	}
+
+	var txnErr error
+	var revision int64
+	if len(ops) > 0 {
+		resp, err := a.kv.Txn(ctx).Then(ops...).Commit()
+		txnErr = err
+		if err == nil && resp != nil && resp.Header != nil {
+			revision = resp.Header.Revision
+		}
+	}
+	for i, done := range dones {
+		if txnErr == nil {
+			a.recordRevision(keys[i], revision)
+		}
+		done <- txnErr
+	}
+}
+
+func (a *etcdAdapter) Delete(ctx context.Context, key string) error {
+	_, err := a.kv.Delete(ctx, key)
+	a.leases.Delete(key)
+	return err
+}
+
+// forgetLeasesWithPrefix evicts every cached lease whose key starts with
+// prefix. Callers that delete a range of keys directly against the raw
+// etcd client (e.g. RevokeByPrefix) rather than through Delete must call
+// this afterwards, or the lease cache accumulates entries for keys etcd no
+// longer has.
+func (a *etcdAdapter) forgetLeasesWithPrefix(prefix string) {
+	a.leases.DeletePrefix(prefix)
+}
+
+// Close releases the underlying etcd client.
+func (a *etcdAdapter) Close() error {
+	return a.client.Close()
+}
@@ -0,0 +1,119 @@
+package etcdstore
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// countingKV and countingLease are minimal clientv3.KV/clientv3.Lease
+// implementations that just count RPCs, so the benchmarks below can show
+// the RPC reduction from lease reuse and write batching without a live
+// etcd cluster.
+type countingKV struct {
+	clientv3.KV
+	puts int64
+	txns int64
+}
+
+func (k *countingKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	atomic.AddInt64(&k.puts, 1)
+	return &clientv3.PutResponse{}, nil
+}
+
+func (k *countingKV) Txn(ctx context.Context) clientv3.Txn {
+	atomic.AddInt64(&k.txns, 1)
+	return &noopTxn{}
+}
+
+type noopTxn struct {
+	clientv3.Txn
+}
+
+func (t *noopTxn) If(cs ...clientv3.Cmp) clientv3.Txn     { return t }
+func (t *noopTxn) Then(ops ...clientv3.Op) clientv3.Txn   { return t }
+func (t *noopTxn) Else(ops ...clientv3.Op) clientv3.Txn   { return t }
+func (t *noopTxn) Commit() (*clientv3.TxnResponse, error) { return &clientv3.TxnResponse{}, nil }
+
+type countingLease struct {
+	clientv3.Lease
+	grants        int64
+	keepAliveOnce int64
+}
+
+func (l *countingLease) Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	atomic.AddInt64(&l.grants, 1)
+	return &clientv3.LeaseGrantResponse{ID: clientv3.LeaseID(atomic.LoadInt64(&l.grants))}, nil
+}
+
+func (l *countingLease) KeepAliveOnce(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseKeepAliveResponse, error) {
+	atomic.AddInt64(&l.keepAliveOnce, 1)
+	return &clientv3.LeaseKeepAliveResponse{ID: id}, nil
+}
+
+func newBenchAdapter() (*etcdAdapter, *countingKV, *countingLease) {
+	kv := &countingKV{}
+	lease := &countingLease{}
+	return &etcdAdapter{kv: kv, lease: lease, leases: newLeaseCache(defaultLeaseCacheCapacity)}, kv, lease
+}
+
+// BenchmarkSaveGrantPerWrite mirrors the original behavior: every save
+// grants a brand new lease.
+func BenchmarkSaveGrantPerWrite(b *testing.B) {
+	a, kv, lease := newBenchAdapter()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := a.putDirect(ctx, "session_same-id", []byte("v"), 30*time.Second); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&lease.grants))/float64(b.N), "grants/op")
+	b.ReportMetric(float64(atomic.LoadInt64(&kv.puts))/float64(b.N), "puts/op")
+}
+
+// BenchmarkSaveWithLeaseReuse shows the same workload with SetLeaseReuse
+// enabled: after the first write, every subsequent save for the same
+// session ID reuses its lease via KeepAliveOnce instead of granting a new
+// one.
+func BenchmarkSaveWithLeaseReuse(b *testing.B) {
+	a, kv, lease := newBenchAdapter()
+	a.SetLeaseReuse(true)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := a.putDirect(ctx, "session_same-id", []byte("v"), 30*time.Second); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&lease.grants))/float64(b.N), "grants/op")
+	b.ReportMetric(float64(atomic.LoadInt64(&kv.puts))/float64(b.N), "puts/op")
+}
+
+// BenchmarkSaveWithWriteBatch shows concurrent saves coalesced into Txns
+// of up to 50 ops apiece. Each goroutine blocks in Put until its batch
+// flushes, so a batch holds at most one op per RunParallel goroutine
+// (GOMAXPROCS by default), and txns/op falls by up to that factor versus
+// one RPC per save.
+func BenchmarkSaveWithWriteBatch(b *testing.B) {
+	a, kv, _ := newBenchAdapter()
+	a.SetWriteBatch(50, 10*time.Millisecond)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			i++
+			if err := a.Put(ctx, "session_concurrent", []byte("v"), 30*time.Second); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.ReportMetric(float64(atomic.LoadInt64(&kv.txns))/float64(b.N), "txns/op")
+}
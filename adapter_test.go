@@ -0,0 +1,81 @@
+package etcdstore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLeaseReuseKeepsSingleLeaseAcrossSaves(t *testing.T) {
+	a, _, lease := newBenchAdapter()
+	a.SetLeaseReuse(true)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if err := a.putDirect(ctx, "session_x", []byte("v"), 30*time.Second); err != nil {
+			t.Fatalf("putDirect() error = %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt64(&lease.grants); got != 1 {
+		t.Fatalf("expected exactly 1 Grant call, got %d", got)
+	}
+	if got := atomic.LoadInt64(&lease.keepAliveOnce); got != 4 {
+		t.Fatalf("expected 4 KeepAliveOnce calls, got %d", got)
+	}
+}
+
+func TestLeaseReuseGrantsFreshLeaseWhenTTLChanges(t *testing.T) {
+	a, _, lease := newBenchAdapter()
+	a.SetLeaseReuse(true)
+	ctx := context.Background()
+
+	if err := a.putDirect(ctx, "session_x", []byte("v"), 30*time.Second); err != nil {
+		t.Fatalf("putDirect() error = %v", err)
+	}
+	if err := a.putDirect(ctx, "session_x", []byte("v"), 60*time.Second); err != nil {
+		t.Fatalf("putDirect() error = %v", err)
+	}
+
+	if got := atomic.LoadInt64(&lease.grants); got != 2 {
+		t.Fatalf("expected a fresh Grant when ttl changes, got %d grants", got)
+	}
+}
+
+func TestWriteBatchFlushesAtMaxOps(t *testing.T) {
+	a, kv, _ := newBenchAdapter()
+	a.SetWriteBatch(3, time.Hour)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.Put(ctx, "session_batched", []byte("v"), 30*time.Second); err != nil {
+				t.Errorf("Put() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt64(&kv.txns); got != 1 {
+		t.Fatalf("expected the 3 writes to flush as a single Txn, got %d", got)
+	}
+}
+
+func TestWriteBatchFlushesAfterMaxDelay(t *testing.T) {
+	a, kv, _ := newBenchAdapter()
+	a.SetWriteBatch(100, 5*time.Millisecond)
+	ctx := context.Background()
+
+	if err := a.Put(ctx, "session_batched", []byte("v"), 30*time.Second); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	if got := atomic.LoadInt64(&kv.txns); got != 1 {
+		t.Fatalf("expected the pending write to flush after maxDelay, got %d txns", got)
+	}
+}
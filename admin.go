@@ -0,0 +1,143 @@
+package etcdstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// ErrNotEtcdBacked is returned by the administrative operations below when
+// the store's KVAdapter isn't backed by a real etcd cluster, since they
+// rely on etcd's native range queries, leases and watches.
+var ErrNotEtcdBacked = errors.New("etcdstore: this operation requires an etcd-backed KVAdapter")
+
+// SessionMeta describes a stored session without decoding its values.
+type SessionMeta struct {
+	// ID is the session ID, with the store's key prefix stripped.
+	ID string
+	// Size is the size, in bytes, of the serialized session value.
+	Size int
+	// TTL is the session's remaining time to live, as reported by its
+	// etcd lease. A TTL <= 0 means the session has no lease (never
+	// expires) or its lease could not be found.
+	TTL time.Duration
+}
+
+// SessionEventType identifies what happened to a session in a SessionEvent.
+type SessionEventType int
+
+const (
+	// SessionPut is emitted when a session is created or updated.
+	SessionPut SessionEventType = iota
+	// SessionDeleted is emitted when a session is deleted or its lease
+	// expires.
+	SessionDeleted
+)
+
+// SessionEvent describes a single change to a session observed via
+// WatchExpirations.
+type SessionEvent struct {
+	Type SessionEventType
+	ID   string
+}
+
+// etcdClient returns the *clientv3.Client backing s, or ErrNotEtcdBacked if
+// s was built with a non-etcd KVAdapter.
+func (s *EtcdStore) etcdClient() (*clientv3.Client, error) {
+	ea, ok := s.adapter.(*etcdAdapter)
+	if !ok {
+		return nil, ErrNotEtcdBacked
+	}
+	return ea.client, nil
+}
+
+// ListSessions returns metadata for every stored session whose ID starts
+// with prefix (the store's own key prefix is applied automatically), using
+// etcd's native range query instead of decoding each session's values.
+func (s *EtcdStore) ListSessions(ctx context.Context, prefix string) ([]SessionMeta, error) {
+	client, err := s.etcdClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Get(ctx, s.keyPrefix+prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	metas := make([]SessionMeta, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		meta := SessionMeta{
+			ID:   strings.TrimPrefix(string(kv.Key), s.keyPrefix),
+			Size: len(kv.Value),
+		}
+		if kv.Lease != 0 {
+			ttl, err := client.TimeToLive(ctx, clientv3.LeaseID(kv.Lease))
+			if err != nil {
+				return nil, err
+			}
+			meta.TTL = time.Duration(ttl.TTL) * time.Second
+		}
+		metas = append(metas, meta)
+	}
+	return metas, nil
+}
+
+// RevokeByPrefix deletes every stored session whose ID starts with prefix
+// in a single etcd range delete, e.g. for mass logout when session IDs
+// embed a user prefix. It returns the number of sessions deleted.
+func (s *EtcdStore) RevokeByPrefix(ctx context.Context, prefix string) (int, error) {
+	ea, ok := s.adapter.(*etcdAdapter)
+	if !ok {
+		return 0, ErrNotEtcdBacked
+	}
+
+	fullPrefix := s.keyPrefix + prefix
+	resp, err := ea.client.Delete(ctx, fullPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, err
+	}
+	// This range delete bypassed Delete(key), which is what normally
+	// evicts a single key's cached lease - forget the whole prefix here
+	// so it doesn't accumulate zombie lease entries for keys etcd no
+	// longer has.
+	ea.forgetLeasesWithPrefix(fullPrefix)
+	return int(resp.Deleted), nil
+}
+
+// WatchExpirations streams SessionEvents for every PUT and DELETE observed
+// under the store's key prefix, built directly on clientv3.Watch. Since
+// etcd already expires sessions via their lease, a DELETE event is both an
+// explicit revocation and a natural expiration - no separate GC goroutine
+// is needed. The returned channel is closed when ctx is done.
+func (s *EtcdStore) WatchExpirations(ctx context.Context) (<-chan SessionEvent, error) {
+	client, err := s.etcdClient()
+	if err != nil {
+		return nil, err
+	}
+
+	wc := client.Watch(ctx, s.keyPrefix, clientv3.WithPrefix())
+	events := make(chan SessionEvent)
+	go func() {
+		defer close(events)
+		for resp := range wc {
+			for _, ev := range resp.Events {
+				evt := SessionEvent{ID: strings.TrimPrefix(string(ev.Kv.Key), s.keyPrefix)}
+				if ev.Type == clientv3.EventTypeDelete {
+					evt.Type = SessionDeleted
+				} else {
+					evt.Type = SessionPut
+				}
+				select {
+				case events <- evt:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
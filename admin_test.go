@@ -0,0 +1,49 @@
+package etcdstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAdminOpsRequireEtcdBackedAdapter(t *testing.T) {
+	store := newTestStore()
+	ctx := context.Background()
+
+	if _, err := store.ListSessions(ctx, ""); !errors.Is(err, ErrNotEtcdBacked) {
+		t.Fatalf("ListSessions() error = %v, want %v", err, ErrNotEtcdBacked)
+	}
+	if _, err := store.RevokeByPrefix(ctx, ""); !errors.Is(err, ErrNotEtcdBacked) {
+		t.Fatalf("RevokeByPrefix() error = %v, want %v", err, ErrNotEtcdBacked)
+	}
+	if _, err := store.WatchExpirations(ctx); !errors.Is(err, ErrNotEtcdBacked) {
+		t.Fatalf("WatchExpirations() error = %v, want %v", err, ErrNotEtcdBacked)
+	}
+}
+
+// TestRevokeByPrefixForgetsLeaseCache guards against the lease-reuse cache
+// (chunk0-6) silently growing forever across mass revocations: a
+// RevokeByPrefix-style range delete bypasses the single-key Delete that
+// would normally evict a lease, so it must explicitly forget every lease
+// under the revoked prefix itself. RevokeByPrefix needs a live etcd client,
+// so this exercises forgetLeasesWithPrefix, the helper it calls after its
+// range delete, directly.
+func TestRevokeByPrefixForgetsLeaseCache(t *testing.T) {
+	a, _, _ := newBenchAdapter()
+	a.SetLeaseReuse(true)
+	a.leases.Set("session_user:1:a", cachedLease{id: 1})
+	a.leases.Set("session_user:1:b", cachedLease{id: 2})
+	a.leases.Set("session_user:2:a", cachedLease{id: 3})
+
+	a.forgetLeasesWithPrefix("session_user:1:")
+
+	if _, ok := a.leases.Get("session_user:1:a"); ok {
+		t.Fatalf("expected \"session_user:1:a\" lease to be forgotten")
+	}
+	if _, ok := a.leases.Get("session_user:1:b"); ok {
+		t.Fatalf("expected \"session_user:1:b\" lease to be forgotten")
+	}
+	if _, ok := a.leases.Get("session_user:2:a"); !ok {
+		t.Fatalf("expected \"session_user:2:a\" lease to remain cached")
+	}
+}
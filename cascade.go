@@ -0,0 +1,306 @@
+package etcdstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// CascadeOptions configures the in-process tiers that sit in front of
+// etcd in a CascadeStore.
+type CascadeOptions struct {
+	// LocalMaxEntries caps the number of sessions held in the
+	// process-local LRU tier. Zero or negative disables that tier, so
+	// reads fall through to the per-request tier and then etcd.
+	LocalMaxEntries int
+	// LocalTTL bounds how long an entry may live in the local LRU tier,
+	// independent of the session's own MaxAge. Zero means entries only
+	// expire by LRU eviction.
+	LocalTTL time.Duration
+	// BypassLocalOnNew skips writing through to the local LRU tier for
+	// sessions that were freshly created (session.IsNew), so a flood of
+	// one-off anonymous sessions doesn't evict hot, returning-user
+	// sessions from the cache.
+	BypassLocalOnNew bool
+	// WatchPrefix, if true, subscribes to an etcd Watch on the store's
+	// key prefix and evicts the local tier's entry whenever a remote PUT
+	// or DELETE is observed, keeping the local cache coherent across
+	// multiple processes sharing the same etcd cluster.
+	WatchPrefix bool
+}
+
+// CascadeStore layers a per-request cache and a process-local TTL LRU in
+// front of an EtcdStore to cut down on etcd RPCs for read-heavy session
+// workloads. Reads walk the tiers cheapest-first (request -> local -> etcd)
+// and populate misses back up; writes fan out to every tier with etcd as
+// the source of truth; deletes propagate downward through every tier.
+type CascadeStore struct {
+	*EtcdStore
+	opts        CascadeOptions
+	local       *lruCache
+	cancelWatch context.CancelFunc
+}
+
+// requestCacheKey is the context key CascadeStore uses to stash its
+// per-request cache, following the same trick sessions.GetRegistry uses to
+// attach the session registry to a request.
+type requestCacheKey struct{}
+
+// requestCache is the cheapest, first tier: values already looked up
+// earlier in the same request.
+type requestCache struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func requestCacheFor(r *http.Request) *requestCache {
+	ctx := r.Context()
+	if rc, ok := ctx.Value(requestCacheKey{}).(*requestCache); ok {
+		return rc
+	}
+	rc := &requestCache{data: make(map[string][]byte)}
+	*r = *r.WithContext(context.WithValue(ctx, requestCacheKey{}, rc))
+	return rc
+}
+
+func (c *requestCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.data[key]
+	return b, ok
+}
+
+func (c *requestCache) set(key string, b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = b
+}
+
+func (c *requestCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, key)
+}
+
+// NewCascadeStore instantiates a CascadeStore backed by client, with its
+// in-process tiers configured by opts.
+func NewCascadeStore(client *clientv3.Client, opts CascadeOptions, keyPairs ...[]byte) *CascadeStore {
+	cs := &CascadeStore{
+		EtcdStore: NewEtcdStore(client, keyPairs...),
+		opts:      opts,
+		local:     newLRUCache(opts.LocalMaxEntries),
+	}
+
+	if opts.WatchPrefix && cs.local != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		cs.cancelWatch = cancel
+		go cs.watchInvalidations(ctx, client)
+	}
+
+	return cs
+}
+
+// Get returns a session for the given name after adding it to the registry.
+//
+// See gorilla/sessions FilesystemStore.Get().
+func (cs *CascadeStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(cs, name)
+}
+
+// New returns a session for the given name without adding it to the
+// registry.
+//
+// See gorilla/sessions FilesystemStore.New().
+func (cs *CascadeStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	var (
+		err error
+		ok  bool
+	)
+	session := sessions.NewSession(cs, name)
+	options := *cs.options
+	session.Options = &options
+	session.IsNew = true
+	if c, errCookie := r.Cookie(name); errCookie == nil {
+		err = securecookie.DecodeMulti(name, c.Value, &session.ID, cs.Codecs...)
+		if err == nil {
+			ok, err = cs.cascadeLoad(r, session)
+			session.IsNew = !(err == nil && ok)
+		}
+	}
+	return session, err
+}
+
+// Save adds a single session to the response, fanning the write out to
+// every cache tier as well as etcd.
+func (cs *CascadeStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		if err := cs.cascadeDelete(r, session); err != nil {
+			return err
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+	} else {
+		if session.ID == "" {
+			id, err := cs.keyGen()
+			if err != nil {
+				return err
+			}
+			session.ID = id
+		}
+		if err := cs.cascadeSave(r, session); err != nil {
+			return err
+		}
+		encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, cs.Codecs...)
+		if err != nil {
+			return err
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	}
+	return nil
+}
+
+// Close stops the invalidation watch, if any, and closes the underlying
+// EtcdStore.
+func (cs *CascadeStore) Close() error {
+	if cs.cancelWatch != nil {
+		cs.cancelWatch()
+	}
+	return cs.EtcdStore.Close()
+}
+
+// Delete removes the session from etcd and every cache tier, sets the
+// cookie to expire and clears the session's values.
+func (cs *CascadeStore) Delete(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if err := cs.cascadeDelete(r, session); err != nil {
+		return err
+	}
+	options := *session.Options
+	options.MaxAge = -1
+	http.SetCookie(w, sessions.NewCookie(session.Name(), "", &options))
+	for k := range session.Values {
+		delete(session.Values, k)
+	}
+	return nil
+}
+
+// RevokeByPrefix deletes every stored session whose ID starts with prefix
+// from etcd, as EtcdStore.RevokeByPrefix does, and also purges any matching
+// entries from the local LRU tier. Without this override, a mass
+// revocation would delete the etcd keys but leave matching sessions
+// servable straight out of cs.local until they expired or were evicted on
+// their own - stale, but still "valid" as far as the local tier was
+// concerned.
+//
+// ListSessions and WatchExpirations aren't overridden: they only read or
+// observe etcd, so promoting EtcdStore's versions unmodified can't itself
+// serve stale revoked data the way a cached Get can.
+func (cs *CascadeStore) RevokeByPrefix(ctx context.Context, prefix string) (int, error) {
+	n, err := cs.EtcdStore.RevokeByPrefix(ctx, prefix)
+	if err != nil {
+		return n, err
+	}
+	if cs.local != nil {
+		cs.local.DeletePrefix(cs.keyPrefix + prefix)
+	}
+	return n, nil
+}
+
+// cascadeLoad walks the tiers cheapest-first, populating misses back up to
+// the tiers that missed.
+func (cs *CascadeStore) cascadeLoad(r *http.Request, session *sessions.Session) (bool, error) {
+	key := cs.keyPrefix + session.ID
+	rc := requestCacheFor(r)
+
+	if b, ok := rc.get(key); ok {
+		return true, cs.serializer.Deserialize(b, session)
+	}
+
+	if cs.local != nil {
+		if b, ok := cs.local.Get(key); ok {
+			rc.set(key, b)
+			return true, cs.serializer.Deserialize(b, session)
+		}
+	}
+
+	b, ok, err := cs.adapter.Get(r.Context(), key)
+	if err != nil || !ok {
+		return false, err
+	}
+	rc.set(key, b)
+	if cs.local != nil {
+		cs.local.Set(key, b, cs.opts.LocalTTL)
+	}
+	return true, cs.serializer.Deserialize(b, session)
+}
+
+// cascadeSave writes through to etcd and then every cache tier.
+func (cs *CascadeStore) cascadeSave(r *http.Request, session *sessions.Session) error {
+	b, err := cs.serializer.Serialize(session)
+	if err != nil {
+		return err
+	}
+	if cs.maxLength != 0 && len(b) > cs.maxLength {
+		return errors.New("SessionStore: the value to store is too big")
+	}
+
+	age := session.Options.MaxAge
+	if age == 0 {
+		age = cs.DefaultMaxAge
+	}
+
+	key := cs.keyPrefix + session.ID
+	if err := cs.adapter.Put(r.Context(), key, b, time.Duration(age)*time.Second); err != nil {
+		return err
+	}
+
+	requestCacheFor(r).set(key, b)
+	if cs.local != nil && !(cs.opts.BypassLocalOnNew && session.IsNew) {
+		cs.local.Set(key, b, cs.opts.LocalTTL)
+		if ea, ok := cs.adapter.(*etcdAdapter); ok {
+			if rev, ok := ea.PutRevision(key); ok {
+				cs.local.SetRevision(key, rev)
+			}
+		}
+	}
+	return nil
+}
+
+// cascadeDelete removes the session from etcd and every cache tier.
+func (cs *CascadeStore) cascadeDelete(r *http.Request, session *sessions.Session) error {
+	key := cs.keyPrefix + session.ID
+	if err := cs.adapter.Delete(r.Context(), key); err != nil {
+		return err
+	}
+	requestCacheFor(r).delete(key)
+	if cs.local != nil {
+		cs.local.Delete(key)
+	}
+	return nil
+}
+
+// watchInvalidations evicts the local tier whenever a PUT or DELETE is
+// observed under the store's prefix, so the local cache doesn't serve stale
+// data after a remote write. PUT events are skipped when their revision
+// matches the one cascadeSave recorded for that key, since that means this
+// same process just wrote it and already populated the local tier with the
+// current value - without this check, enabling WatchPrefix would make every
+// write immediately evict the entry it just cached.
+func (cs *CascadeStore) watchInvalidations(ctx context.Context, client *clientv3.Client) {
+	wc := client.Watch(ctx, cs.keyPrefix, clientv3.WithPrefix())
+	for resp := range wc {
+		for _, ev := range resp.Events {
+			key := string(ev.Kv.Key)
+			if ev.Type == clientv3.EventTypePut {
+				if rev, ok := cs.local.Revision(key); ok && rev == ev.Kv.ModRevision {
+					continue
+				}
+			}
+			cs.local.Delete(key)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package etcdstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func newTestCascadeStore(opts CascadeOptions) *CascadeStore {
+	return &CascadeStore{
+		EtcdStore: newTestStore(),
+		opts:      opts,
+		local:     newLRUCache(opts.LocalMaxEntries),
+	}
+}
+
+// TestCascadeLoadPopulatesTiersCheapestFirst checks that cascadeLoad checks
+// the request cache before the local tier, and the local tier before the
+// backing adapter, populating each cheaper tier it missed along the way.
+func TestCascadeLoadPopulatesTiersCheapestFirst(t *testing.T) {
+	cs := newTestCascadeStore(CascadeOptions{LocalMaxEntries: 10})
+	key := cs.keyPrefix + "sess-1"
+
+	etcdSession := sessions.NewSession(cs, "session")
+	etcdSession.Values["source"] = "etcd"
+	etcdBytes, err := cs.serializer.Serialize(etcdSession)
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	if err := cs.adapter.Put(context.Background(), key, etcdBytes, 0); err != nil {
+		t.Fatalf("adapter.Put() error = %v", err)
+	}
+
+	// Nothing cached yet: cascadeLoad should fall through to the adapter
+	// and populate both the local tier and the request cache on its way
+	// back up.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session := sessions.NewSession(cs, "session")
+	session.ID = "sess-1"
+	if ok, err := cs.cascadeLoad(req, session); err != nil || !ok {
+		t.Fatalf("cascadeLoad() = %v, %v, want true, nil", ok, err)
+	}
+	if session.Values["source"] != "etcd" {
+		t.Fatalf("session.Values[source] = %v, want etcd", session.Values["source"])
+	}
+	if _, ok := cs.local.Get(key); !ok {
+		t.Fatalf("expected cascadeLoad to populate the local tier from the adapter")
+	}
+	if _, ok := requestCacheFor(req).get(key); !ok {
+		t.Fatalf("expected cascadeLoad to populate the request cache")
+	}
+
+	// Overwrite the local tier directly, simulating it being ahead of the
+	// adapter (e.g. this process's own recent write). A fresh request
+	// should read the local tier's value rather than the adapter's.
+	localSession := sessions.NewSession(cs, "session")
+	localSession.Values["source"] = "local"
+	localBytes, err := cs.serializer.Serialize(localSession)
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	cs.local.Set(key, localBytes, 0)
+
+	req2 := httptest.NewRequest(http.MethodGet, "/", nil)
+	session2 := sessions.NewSession(cs, "session")
+	session2.ID = "sess-1"
+	if ok, err := cs.cascadeLoad(req2, session2); err != nil || !ok {
+		t.Fatalf("cascadeLoad() = %v, %v, want true, nil", ok, err)
+	}
+	if session2.Values["source"] != "local" {
+		t.Fatalf("expected cascadeLoad to prefer the local tier over the adapter, got %v", session2.Values["source"])
+	}
+
+	// Seed the per-request cache directly: it should be preferred over
+	// both the local tier and the adapter.
+	requestSession := sessions.NewSession(cs, "session")
+	requestSession.Values["source"] = "request"
+	requestBytes, err := cs.serializer.Serialize(requestSession)
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+
+	req3 := httptest.NewRequest(http.MethodGet, "/", nil)
+	requestCacheFor(req3).set(key, requestBytes)
+	session3 := sessions.NewSession(cs, "session")
+	session3.ID = "sess-1"
+	if ok, err := cs.cascadeLoad(req3, session3); err != nil || !ok {
+		t.Fatalf("cascadeLoad() = %v, %v, want true, nil", ok, err)
+	}
+	if session3.Values["source"] != "request" {
+		t.Fatalf("expected cascadeLoad to prefer the request cache, got %v", session3.Values["source"])
+	}
+}
+
+// TestCascadeSaveBypassesLocalForNewSessions checks that
+// CascadeOptions.BypassLocalOnNew keeps freshly created sessions out of the
+// local LRU tier while still writing them through to the adapter and the
+// per-request cache.
+func TestCascadeSaveBypassesLocalForNewSessions(t *testing.T) {
+	cs := newTestCascadeStore(CascadeOptions{LocalMaxEntries: 10, BypassLocalOnNew: true})
+	key := cs.keyPrefix + "sess-new"
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session := sessions.NewSession(cs, "session")
+	session.Options = &sessions.Options{MaxAge: 60}
+	session.ID = "sess-new"
+	session.IsNew = true
+	session.Values["x"] = "1"
+
+	if err := cs.cascadeSave(req, session); err != nil {
+		t.Fatalf("cascadeSave() error = %v", err)
+	}
+
+	if _, ok := cs.local.Get(key); ok {
+		t.Fatalf("expected a new session to bypass the local tier")
+	}
+	if _, ok := requestCacheFor(req).get(key); !ok {
+		t.Fatalf("expected cascadeSave to still populate the request cache")
+	}
+	if _, ok, err := cs.adapter.Get(context.Background(), key); err != nil || !ok {
+		t.Fatalf("adapter.Get() = %v, %v, want true, nil", ok, err)
+	}
+}
+
+// TestCascadeRevokeByPrefixRequiresEtcdBackedAdapter checks that
+// CascadeStore's RevokeByPrefix override still reports ErrNotEtcdBacked
+// (rather than silently purging the local tier) when it isn't backed by a
+// real etcd cluster.
+func TestCascadeRevokeByPrefixRequiresEtcdBackedAdapter(t *testing.T) {
+	cs := newTestCascadeStore(CascadeOptions{LocalMaxEntries: 10})
+	if _, err := cs.RevokeByPrefix(context.Background(), ""); !errors.Is(err, ErrNotEtcdBacked) {
+		t.Fatalf("RevokeByPrefix() error = %v, want %v", err, ErrNotEtcdBacked)
+	}
+}
+
+// TestCascadeDeletePropagatesToAllTiers checks that cascadeDelete removes a
+// session from the request cache, the local tier and the adapter alike.
+func TestCascadeDeletePropagatesToAllTiers(t *testing.T) {
+	cs := newTestCascadeStore(CascadeOptions{LocalMaxEntries: 10})
+	key := cs.keyPrefix + "sess-del"
+
+	session := sessions.NewSession(cs, "session")
+	session.Options = &sessions.Options{MaxAge: 60}
+	session.ID = "sess-del"
+	session.Values["x"] = "1"
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := cs.cascadeSave(req, session); err != nil {
+		t.Fatalf("cascadeSave() error = %v", err)
+	}
+	if _, ok := cs.local.Get(key); !ok {
+		t.Fatalf("expected local tier to be populated before delete")
+	}
+	if _, ok := requestCacheFor(req).get(key); !ok {
+		t.Fatalf("expected request cache to be populated before delete")
+	}
+
+	if err := cs.cascadeDelete(req, session); err != nil {
+		t.Fatalf("cascadeDelete() error = %v", err)
+	}
+
+	if _, ok := cs.local.Get(key); ok {
+		t.Fatalf("expected cascadeDelete to evict the local tier entry")
+	}
+	if _, ok := requestCacheFor(req).get(key); ok {
+		t.Fatalf("expected cascadeDelete to evict the request cache entry")
+	}
+	if _, ok, err := cs.adapter.Get(context.Background(), key); err != nil || ok {
+		t.Fatalf("adapter.Get() = %v, %v, want false, nil", ok, err)
+	}
+}
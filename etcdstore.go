@@ -8,8 +8,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	ginsessions "github.com/gin-contrib/sessions"
 	"github.com/gorilla/securecookie"
@@ -78,15 +80,31 @@ func (s GobSerializer) Deserialize(d []byte, ss *sessions.Session) error {
 	return dec.Decode(&ss.Values)
 }
 
-// EtcdStore stores sessions in a etcd backend.
+// KeyGenFunc generates a new session ID. It must return an error rather
+// than an empty/weak ID if it cannot generate one securely.
+type KeyGenFunc func() (string, error)
+
+// defaultKeyGen is the base32-of-32-random-bytes generator EtcdStore has
+// always used.
+func defaultKeyGen() (string, error) {
+	b := securecookie.GenerateRandomKey(32)
+	if b == nil {
+		return "", errors.New("etcdstore: failed to generate a random session key")
+	}
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(b), "="), nil
+}
+
+// EtcdStore stores sessions in a TTL-capable key/value backend, etcd by
+// default.
 type EtcdStore struct {
-	client        *clientv3.Client
+	adapter       KVAdapter
 	Codecs        []securecookie.Codec
 	options       *sessions.Options // default configuration
 	DefaultMaxAge int               // default Etcd TTL for a MaxAge == 0 session
 	maxLength     int
 	keyPrefix     string
 	serializer    SessionSerializer
+	keyGen        KeyGenFunc
 }
 
 // SetMaxLength sets EtcdStore.maxLength if the `l` argument is greater or equal 0
@@ -111,11 +129,60 @@ func (s *EtcdStore) SetSerializer(ss SessionSerializer) {
 	s.serializer = ss
 }
 
+// SetKeyGen overrides how new session IDs are generated. This allows
+// prefixed IDs (e.g. tenant-scoped), UUIDv7 for time-ordered etcd keys that
+// improve range-scan locality, or HMAC-bound IDs. The default generates a
+// base32-encoded, 32-byte random value.
+func (s *EtcdStore) SetKeyGen(kg KeyGenFunc) {
+	s.keyGen = kg
+}
+
+// SetLeaseReuse enables reusing a single etcd lease per session ID across
+// saves, refreshing it with KeepAliveOnce instead of granting a new lease
+// every time the session's age is unchanged. This cuts the number of
+// leases (and RPCs) etcd has to track under load. It is a no-op unless the
+// store is backed by the default etcd KVAdapter.
+func (s *EtcdStore) SetLeaseReuse(enable bool) {
+	if ea, ok := s.adapter.(*etcdAdapter); ok {
+		ea.SetLeaseReuse(enable)
+	}
+}
+
+// SetWriteBatch enables coalescing concurrent saves into a single etcd Txn:
+// writes queue until maxOps are pending or maxDelay has elapsed since the
+// first of them, whichever comes first, then flush together. Pass
+// maxOps <= 0 to disable batching. It is a no-op unless the store is
+// backed by the default etcd KVAdapter.
+func (s *EtcdStore) SetWriteBatch(maxOps int, maxDelay time.Duration) {
+	if ea, ok := s.adapter.(*etcdAdapter); ok {
+		ea.SetWriteBatch(maxOps, maxDelay)
+	}
+}
+
+// SetEncryptValues switches the store to a SecureCookieSerializer built
+// from the store's own Codecs, so session values are encrypted/
+// authenticated before being written to the backend instead of stored as
+// plain gob. Passing false restores the default GobSerializer.
+//
+// Call this after the store's Codecs are final: SecureCookieSerializer
+// keeps the slice s.Codecs holds at the time of the call, so assigning a
+// new slice to s.Codecs afterwards won't be picked up unless
+// SetEncryptValues is called again. SetMaxAge changes the existing codecs
+// in place, so it does carry over.
+func (s *EtcdStore) SetEncryptValues(b bool) {
+	if b {
+		s.serializer = SecureCookieSerializer{Codecs: s.Codecs}
+	} else {
+		s.serializer = GobSerializer{}
+	}
+}
+
 // SetMaxAge restricts the maximum age, in seconds, of the session record
 // both in database and a browser. This is to change session storage configuration.
 // If you want just to remove session use your session `s` object and change it's
 // `Options.MaxAge` to -1, as specified in
-//    http://godoc.org/github.com/gorilla/sessions#Options
+//
+//	http://godoc.org/github.com/gorilla/sessions#Options
 //
 // Default is the one provided by this package value - `sessionExpire`.
 // Set it to 0 for no restriction.
@@ -136,27 +203,22 @@ func (s *EtcdStore) SetMaxAge(v int) {
 
 // NewEtcdStore instantiates a EtcdStore with a *clientv3.Config passed in.
 func NewEtcdStore(client *clientv3.Client, keyPairs ...[]byte) *EtcdStore {
-	es := &EtcdStore{
-		client: client,
-		Codecs: securecookie.CodecsFromPairs(keyPairs...),
-		options: &sessions.Options{
-			Path:   "/",
-			MaxAge: sessionExpire,
-		},
-		DefaultMaxAge: 60 * 20, // 20 minutes seems like a reasonable default
-		maxLength:     4096,
-		keyPrefix:     "session_",
-		serializer:    GobSerializer{},
-	}
-
-	return es
+	return NewKVStore(NewEtcdAdapter(client), keyPairs...)
 }
 
-// NewEtcdStore instantiates a EtcdStore with a *clientv3.Config passed in.
+// NewEtcdGinStore instantiates a EtcdStore with a *clientv3.Config passed in.
 func NewEtcdGinStore(client *clientv3.Client, keyPairs ...[]byte) ginsessions.Store {
-	es := &EtcdStore{
-		client: client,
-		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+	return NewKVStore(NewEtcdAdapter(client), keyPairs...)
+}
+
+// NewKVStore instantiates an EtcdStore backed by any KVAdapter, so callers
+// can swap in a different TTL-capable key/value backend (Redis, TiKV, an
+// in-memory store for tests, ...) while reusing the same
+// session-serialization and cookie-encoding logic.
+func NewKVStore(adapter KVAdapter, keyPairs ...[]byte) *EtcdStore {
+	return &EtcdStore{
+		adapter: adapter,
+		Codecs:  securecookie.CodecsFromPairs(keyPairs...),
 		options: &sessions.Options{
 			Path:   "/",
 			MaxAge: sessionExpire,
@@ -165,14 +227,16 @@ func NewEtcdGinStore(client *clientv3.Client, keyPairs ...[]byte) ginsessions.St
 		maxLength:     4096,
 		keyPrefix:     "session_",
 		serializer:    GobSerializer{},
+		keyGen:        defaultKeyGen,
 	}
-
-	return es
 }
 
-// Close closes the underlying *etcd.Pool
+// Close closes the underlying KVAdapter, if it supports closing.
 func (s *EtcdStore) Close() error {
-	return s.client.Close()
+	if c, ok := s.adapter.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 // Get returns a session for the given name after adding it to the registry.
@@ -216,7 +280,11 @@ func (s *EtcdStore) Save(r *http.Request, w http.ResponseWriter, session *sessio
 	} else {
 		// Build an alphanumeric key for the etcd store.
 		if session.ID == "" {
-			session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+			id, err := s.keyGen()
+			if err != nil {
+				return err
+			}
+			session.ID = id
 		}
 		if err := s.save(r.Context(), session); err != nil {
 			return err
@@ -235,7 +303,7 @@ func (s *EtcdStore) Save(r *http.Request, w http.ResponseWriter, session *sessio
 // WARNING: This method should be considered deprecated since it is not exposed via the gorilla/sessions interface.
 // Set session.Options.MaxAge = -1 and call Save instead. - July 18th, 2013
 func (s *EtcdStore) Delete(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
-	if _, err := s.client.Delete(r.Context(), s.keyPrefix+session.ID); err != nil {
+	if err := s.adapter.Delete(r.Context(), s.keyPrefix+session.ID); err != nil {
 		return err
 	}
 	// Set cookie to expire.
@@ -271,35 +339,24 @@ func (s *EtcdStore) save(ctx context.Context, session *sessions.Session) error {
 		age = s.DefaultMaxAge
 	}
 
-	lease, err := s.client.Grant(ctx, int64(age))
-	if err != nil {
-		return err
-	}
-
-	_, err = s.client.Put(ctx, s.keyPrefix+session.ID, string(b), clientv3.WithLease(lease.ID))
-	return err
+	return s.adapter.Put(ctx, s.keyPrefix+session.ID, b, time.Duration(age)*time.Second)
 }
 
-// load reads the session from etcd.
+// load reads the session from the backend.
 // returns true if there is a sessoin data in DB
 func (s *EtcdStore) load(ctx context.Context, session *sessions.Session) (bool, error) {
-
-	data, err := s.client.Get(ctx, s.keyPrefix+session.ID)
+	data, ok, err := s.adapter.Get(ctx, s.keyPrefix+session.ID)
 	if err != nil {
 		return false, err
 	}
-
-	if data == nil || len(data.Kvs) != 1 {
+	if !ok {
 		return false, nil // no data was associated with this key
 	}
 
-	return true, s.serializer.Deserialize(data.Kvs[0].Value, session)
+	return true, s.serializer.Deserialize(data, session)
 }
 
-// delete removes keys from etcd if MaxAge<0
+// delete removes keys from the backend if MaxAge<0
 func (s *EtcdStore) delete(ctx context.Context, session *sessions.Session) error {
-	if _, err := s.client.Delete(ctx, s.keyPrefix+session.ID); err != nil {
-		return err
-	}
-	return nil
+	return s.adapter.Delete(ctx, s.keyPrefix+session.ID)
 }
@@ -0,0 +1,163 @@
+package etcdstore
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func newTestStore() *EtcdStore {
+	return NewKVStore(NewMemoryAdapter(), []byte("secret-key-for-testing"))
+}
+
+func TestSaveAndLoadRoundTrip(t *testing.T) {
+	store := newTestStore()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, err := store.New(req, "session")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !session.IsNew {
+		t.Fatalf("expected a fresh session to be IsNew")
+	}
+	session.Values["user_id"] = "42"
+
+	rec := httptest.NewRecorder()
+	if err := store.Save(req, rec, session); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected 1 cookie, got %d", len(cookies))
+	}
+
+	req2 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req2.AddCookie(cookies[0])
+	loaded, err := store.New(req2, "session")
+	if err != nil {
+		t.Fatalf("New() on reload error = %v", err)
+	}
+	if loaded.IsNew {
+		t.Fatalf("expected reloaded session to not be IsNew")
+	}
+	if loaded.Values["user_id"] != "42" {
+		t.Fatalf("expected user_id = 42, got %v", loaded.Values["user_id"])
+	}
+}
+
+func TestSaveAndLoadRoundTripWithEncryptedValues(t *testing.T) {
+	store := newTestStore()
+	store.SetEncryptValues(true)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, err := store.New(req, "session")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	session.Values["user_id"] = "42"
+
+	rec := httptest.NewRecorder()
+	if err := store.Save(req, rec, session); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	rawValue, ok, err := store.adapter.Get(req.Context(), store.keyPrefix+session.ID)
+	if err != nil || !ok {
+		t.Fatalf("expected a stored value, ok = %v, err = %v", ok, err)
+	}
+	if string(rawValue) == string(mustGobSerialize(t, session)) {
+		t.Fatalf("expected the stored value to be securecookie-encoded, got raw gob bytes")
+	}
+
+	cookies := rec.Result().Cookies()
+	req2 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req2.AddCookie(cookies[0])
+	loaded, err := store.New(req2, "session")
+	if err != nil {
+		t.Fatalf("New() on reload error = %v", err)
+	}
+	if loaded.IsNew {
+		t.Fatalf("expected reloaded session to not be IsNew")
+	}
+	if loaded.Values["user_id"] != "42" {
+		t.Fatalf("expected user_id = 42, got %v", loaded.Values["user_id"])
+	}
+}
+
+func mustGobSerialize(t *testing.T, session *sessions.Session) []byte {
+	t.Helper()
+	b, err := (GobSerializer{}).Serialize(session)
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	return b
+}
+
+func TestSetKeyGenIsUsedForNewSessionIDs(t *testing.T) {
+	store := newTestStore()
+	store.SetKeyGen(func() (string, error) {
+		return "fixed-id", nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, err := store.New(req, "session")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	if err := store.Save(req, rec, session); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if session.ID != "fixed-id" {
+		t.Fatalf("expected session.ID = \"fixed-id\", got %q", session.ID)
+	}
+}
+
+func TestSaveReturnsKeyGenError(t *testing.T) {
+	store := newTestStore()
+	wantErr := errors.New("boom")
+	store.SetKeyGen(func() (string, error) {
+		return "", wantErr
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, err := store.New(req, "session")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	if err := store.Save(req, rec, session); err != wantErr {
+		t.Fatalf("Save() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestSaveWithNegativeMaxAgeDeletes(t *testing.T) {
+	store := newTestStore()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session := sessions.NewSession(store, "session")
+	session.Options = &sessions.Options{Path: "/", MaxAge: store.DefaultMaxAge}
+	session.Values["user_id"] = "42"
+
+	rec := httptest.NewRecorder()
+	if err := store.Save(req, rec, session); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	session.Options.MaxAge = -1
+	rec2 := httptest.NewRecorder()
+	if err := store.Save(req, rec2, session); err != nil {
+		t.Fatalf("Save() on delete error = %v", err)
+	}
+
+	if _, ok, err := store.adapter.Get(req.Context(), store.keyPrefix+session.ID); err != nil || ok {
+		t.Fatalf("expected key to be gone, ok = %v, err = %v", ok, err)
+	}
+}
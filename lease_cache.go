@@ -0,0 +1,99 @@
+package etcdstore
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// defaultLeaseCacheCapacity caps how many (key -> lease) mappings
+// etcdAdapter remembers for lease reuse. Without a bound, sessions that
+// are abandoned rather than explicitly deleted (a cookie simply expiring
+// in the browser) would leak an entry forever.
+const defaultLeaseCacheCapacity = 10000
+
+// leaseCache is a size-bounded, least-recently-used cache of the lease
+// granted for a given key, used by etcdAdapter's lease-reuse tier. It is
+// safe for concurrent use.
+type leaseCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type leaseCacheEntry struct {
+	key   string
+	lease cachedLease
+}
+
+// newLeaseCache returns a leaseCache capped at capacity entries. A
+// capacity <= 0 falls back to defaultLeaseCacheCapacity.
+func newLeaseCache(capacity int) *leaseCache {
+	if capacity <= 0 {
+		capacity = defaultLeaseCacheCapacity
+	}
+	return &leaseCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *leaseCache) Get(key string) (cachedLease, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return cachedLease{}, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*leaseCacheEntry).lease, true
+}
+
+func (c *leaseCache) Set(key string, lease cachedLease) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*leaseCacheEntry).lease = lease
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&leaseCacheEntry{key: key, lease: lease})
+	c.items[key] = el
+	if c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *leaseCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// DeletePrefix evicts every cached lease whose key starts with prefix, so
+// a bulk deletion like RevokeByPrefix doesn't leave zombie lease entries
+// behind for keys that etcd no longer has.
+func (c *leaseCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+		}
+	}
+}
+
+// removeElement must be called with c.mu held.
+func (c *leaseCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*leaseCacheEntry).key)
+}
@@ -0,0 +1,48 @@
+package etcdstore
+
+import "testing"
+
+func TestLeaseCacheEvictsOldestOverCapacity(t *testing.T) {
+	c := newLeaseCache(2)
+
+	c.Set("a", cachedLease{id: 1})
+	c.Set("b", cachedLease{id: 2})
+	c.Set("c", cachedLease{id: 3})
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected \"a\" to have been evicted")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatalf("expected \"b\" to still be cached")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Fatalf("expected \"c\" to still be cached")
+	}
+}
+
+func TestLeaseCacheDeletePrefix(t *testing.T) {
+	c := newLeaseCache(defaultLeaseCacheCapacity)
+
+	c.Set("session_user:1:a", cachedLease{id: 1})
+	c.Set("session_user:1:b", cachedLease{id: 2})
+	c.Set("session_user:2:a", cachedLease{id: 3})
+
+	c.DeletePrefix("session_user:1:")
+
+	if _, ok := c.Get("session_user:1:a"); ok {
+		t.Fatalf("expected \"session_user:1:a\" to have been evicted")
+	}
+	if _, ok := c.Get("session_user:1:b"); ok {
+		t.Fatalf("expected \"session_user:1:b\" to have been evicted")
+	}
+	if _, ok := c.Get("session_user:2:a"); !ok {
+		t.Fatalf("expected \"session_user:2:a\" to still be cached")
+	}
+}
+
+func TestNewLeaseCacheFallsBackToDefaultCapacity(t *testing.T) {
+	c := newLeaseCache(0)
+	if c.capacity != defaultLeaseCacheCapacity {
+		t.Fatalf("capacity = %d, want %d", c.capacity, defaultLeaseCacheCapacity)
+	}
+}
@@ -0,0 +1,140 @@
+package etcdstore
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lruCache is a small, size-bounded, per-entry-TTL cache used as the
+// process-local tier of a CascadeStore. It is safe for concurrent use.
+type lruCache struct {
+	mu      sync.Mutex
+	maxSize int
+	ll      *list.List
+	items   map[string]*list.Element
+}
+
+type lruEntry struct {
+	key      string
+	value    []byte
+	expires  time.Time // zero means no expiry
+	revision int64     // etcd mod revision this entry was last written at by us; 0 if unknown
+}
+
+// newLRUCache returns an lruCache capped at maxSize entries, or nil if
+// maxSize <= 0, meaning the local tier is disabled.
+func newLRUCache(maxSize int) *lruCache {
+	if maxSize <= 0 {
+		return nil
+	}
+	return &lruCache{
+		maxSize: maxSize,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached value for key, evicting it first if its TTL has
+// elapsed.
+func (c *lruCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.removeElement(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+// Set stores value under key, expiring it after ttl (or never, if ttl <= 0),
+// and evicts the least-recently-used entry if the cache is over capacity.
+func (c *lruCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		entry.revision = 0
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	c.items[key] = el
+	if c.ll.Len() > c.maxSize {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// SetRevision records the etcd mod revision at which key was last written
+// by this process, if key is still cached. watchInvalidations uses this to
+// recognize its own writes and skip evicting the entry it just populated.
+func (c *lruCache) SetRevision(key string, revision int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).revision = revision
+	}
+}
+
+// Revision returns the mod revision recorded by SetRevision for key, if
+// any (false if key isn't cached or was cached without a known revision).
+func (c *lruCache) Revision(key string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return 0, false
+	}
+	entry := el.Value.(*lruEntry)
+	if entry.revision == 0 {
+		return 0, false
+	}
+	return entry.revision, true
+}
+
+// Delete evicts key, if present.
+func (c *lruCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// DeletePrefix evicts every cached entry whose key starts with prefix.
+func (c *lruCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+		}
+	}
+}
+
+// removeElement must be called with c.mu held.
+func (c *lruCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
@@ -0,0 +1,61 @@
+package etcdstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEvictsOldestOverCapacity(t *testing.T) {
+	c := newLRUCache(2)
+
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+	c.Set("c", []byte("3"), 0)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected \"a\" to have been evicted")
+	}
+	if v, ok := c.Get("b"); !ok || string(v) != "2" {
+		t.Fatalf("expected \"b\" to still be cached, got %q, ok=%v", v, ok)
+	}
+	if v, ok := c.Get("c"); !ok || string(v) != "3" {
+		t.Fatalf("expected \"c\" to still be cached, got %q, ok=%v", v, ok)
+	}
+}
+
+func TestLRUCacheExpiresByTTL(t *testing.T) {
+	c := newLRUCache(10)
+
+	c.Set("a", []byte("1"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected \"a\" to have expired")
+	}
+}
+
+func TestLRUCacheDeletePrefix(t *testing.T) {
+	c := newLRUCache(10)
+
+	c.Set("session_user:1:a", []byte("1"), 0)
+	c.Set("session_user:1:b", []byte("2"), 0)
+	c.Set("session_user:2:a", []byte("3"), 0)
+
+	c.DeletePrefix("session_user:1:")
+
+	if _, ok := c.Get("session_user:1:a"); ok {
+		t.Fatalf("expected \"session_user:1:a\" to have been evicted")
+	}
+	if _, ok := c.Get("session_user:1:b"); ok {
+		t.Fatalf("expected \"session_user:1:b\" to have been evicted")
+	}
+	if _, ok := c.Get("session_user:2:a"); !ok {
+		t.Fatalf("expected \"session_user:2:a\" to still be cached")
+	}
+}
+
+func TestNewLRUCacheDisabledWhenMaxSizeZero(t *testing.T) {
+	if newLRUCache(0) != nil {
+		t.Fatalf("expected a zero max size to disable the cache")
+	}
+}
@@ -0,0 +1,60 @@
+package etcdstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryAdapter is a KVAdapter backed by a process-local map. It has no
+// durability or cross-process visibility and exists mainly so EtcdStore
+// can be exercised in unit tests without a running etcd cluster.
+type MemoryAdapter struct {
+	mu   sync.Mutex
+	data map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time // zero means no expiry
+}
+
+// NewMemoryAdapter returns an empty MemoryAdapter.
+func NewMemoryAdapter() *MemoryAdapter {
+	return &MemoryAdapter{data: make(map[string]memoryEntry)}
+}
+
+func (a *MemoryAdapter) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, found := a.data[key]
+	if !found {
+		return nil, false, nil
+	}
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		delete(a.data, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (a *MemoryAdapter) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	a.data[key] = entry
+	return nil
+}
+
+func (a *MemoryAdapter) Delete(ctx context.Context, key string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.data, key)
+	return nil
+}